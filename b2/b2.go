@@ -7,7 +7,11 @@ import (
 	"hash"
 	"io"
 	"log"
+	"net/http"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -16,57 +20,480 @@ import (
 
 // B2 is a Backblaze client.
 type Client struct {
-	b2 *base.B2
+	// b2 holds the Client's current *base.B2 session. It's stored in an
+	// atomic.Value, rather than as a plain field, because reauthorize
+	// replaces it (from under authMu) concurrently with the many other
+	// goroutines (one per Writer/Reader thread, potentially) that read it
+	// on every call via getB2.
+	b2 atomic.Value
+
+	// account, key, and opts are retained so the Client can silently
+	// re-authorize itself, via reauthorize, when its token expires.
+	account, key string
+	opts         clientOptions
+
+	authMu sync.Mutex
+	// authEpoch counts successful re-authorizations, so that upload and
+	// part URLs cached before the most recent one (which are tied to the
+	// old API host) can be recognized as stale and discarded rather than
+	// reused.
+	authEpoch int64
+
+	stats metrics
+
+	slock    sync.Mutex
+	sWriters map[string]*Writer
+	sReaders map[string]*Reader
+}
+
+// ClientOption configures a Client created with NewClient.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	testMode string
+}
+
+// FailSomeUploads causes the server to randomly return failures for
+// upload calls, for exercising a Writer's retry path in tests.  It should
+// never be used against a production account.
+func FailSomeUploads() ClientOption {
+	return func(o *clientOptions) { o.testMode = "fail_some_uploads" }
+}
+
+// ExpireSomeAuthTokens causes the server to randomly return an expired-auth
+// error, for exercising re-authorization logic in tests.
+func ExpireSomeAuthTokens() ClientOption {
+	return func(o *clientOptions) { o.testMode = "expire_some_account_authorization_tokens" }
+}
+
+// ForceCapExceeded causes the server to respond as though the account's
+// storage cap has been exceeded, for exercising cap-handling logic in
+// tests.
+func ForceCapExceeded() ClientOption {
+	return func(o *clientOptions) { o.testMode = "force_cap_exceeded" }
 }
 
 // NewClient returns a new Backblaze B2 client.
-func NewClient(ctx context.Context, account, key string) (*Client, error) {
+func NewClient(ctx context.Context, account, key string, opts ...ClientOption) (*Client, error) {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 	b2, err := base.B2AuthorizeAccount(ctx, account, key)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{
-		b2: b2,
-	}, nil
+	if o.testMode != "" {
+		b2.SetTestMode(o.testMode)
+	}
+	c := &Client{
+		account: account,
+		key:     key,
+		opts:    o,
+	}
+	c.b2.Store(b2)
+	return c, nil
+}
+
+// getB2 returns the Client's current *base.B2 session.
+func (c *Client) getB2() *base.B2 {
+	return c.b2.Load().(*base.B2)
+}
+
+// maxCallRetries bounds the exponential backoff call applies to retryable
+// errors (5xx and 429 responses), as well as the number of times it will
+// transparently re-authorize and retry after an expired auth token.
+const maxCallRetries = 7 // 1s, 2s, 4s, 8s, 16s, 32s, 64s
+
+// isExpiredAuthToken reports whether err is the B2 "expired_auth_token"
+// error, which every API call can return once the account's authorization
+// token (obtained at NewClient time) turns 24h old or is invalidated
+// server-side.
+func isExpiredAuthToken(err error) bool {
+	ce, ok := err.(interface{ Code() string })
+	return ok && ce.Code() == "expired_auth_token"
+}
+
+// isRetryableStatus reports whether err is a transient failure (server
+// error or rate limiting) worth retrying with backoff, as opposed to one
+// the caller should see immediately.
+func isRetryableStatus(err error) bool {
+	se, ok := err.(interface{ StatusCode() int })
+	if !ok {
+		return false
+	}
+	code := se.StatusCode()
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// reauthorize re-authorizes c's account under authMu, so that concurrent
+// callers that all observe an expired token collapse into a single
+// B2AuthorizeAccount call, and bumps authEpoch so that cached upload and
+// part URLs (which are tied to the old API host) are recognized as stale.
+func (c *Client) reauthorize(ctx context.Context) error {
+	before := c.epoch()
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if c.epoch() != before {
+		// Another goroutine already re-authorized while we were
+		// waiting on authMu; our token is expired too, but there's no
+		// need to hit B2AuthorizeAccount again for it.
+		return nil
+	}
+	b2, err := base.B2AuthorizeAccount(ctx, c.account, c.key)
+	if err != nil {
+		return err
+	}
+	if c.opts.testMode != "" {
+		b2.SetTestMode(c.opts.testMode)
+	}
+	c.b2.Store(b2)
+	atomic.AddInt64(&c.authEpoch, 1)
+	return nil
+}
+
+// epoch returns the Client's current authEpoch, or 0 for a nil Client.
+func (c *Client) epoch() int64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.authEpoch)
+}
+
+// call instruments a single outgoing B2 API call, identified by method (the
+// B2 API name, e.g. "b2_upload_file"), recording its latency and outcome in
+// c's stats.  f should perform exactly one such call and return the error
+// it produced.  On an expired auth token, call transparently re-authorizes
+// and retries; on a 5xx or 429 response, it retries with exponential
+// backoff; both kinds of retry are capped at maxCallRetries.  call is safe
+// to use on a nil *Client, in which case it just runs f once.
+//
+// call should only be used at call sites that don't already have their own
+// caller-driven retry loop (Writer.thread and Reader.fetchChunk do, and use
+// callOnce instead): otherwise its backoff-retry would silently absorb
+// failures the caller thinks it's counting against its own retry budget.
+func (c *Client) call(method string, f func() error) error {
+	return c.doCall(method, f, true)
+}
+
+// callOnce is like call, except it never retries a 5xx or 429 response with
+// backoff; it still transparently re-authorizes and retries on an expired
+// auth token (up to maxCallRetries times), since callers have no way to
+// detect or handle that themselves.  Use it at call sites that already have their own
+// caller-driven retry loop.
+func (c *Client) callOnce(method string, f func() error) error {
+	return c.doCall(method, f, false)
+}
+
+func (c *Client) doCall(method string, f func() error, backoffRetry bool) error {
+	if c == nil {
+		return f()
+	}
+	var err error
+	backoff := time.Second
+	for attempt := 0; attempt <= maxCallRetries; attempt++ {
+		m := c.stats.forMethod(method)
+		atomic.AddInt64(&m.inFlight, 1)
+		start := time.Now()
+		err = f()
+		atomic.AddInt64(&m.inFlight, -1)
+		m.record(time.Since(start), err)
+		if err == nil || attempt == maxCallRetries {
+			return err
+		}
+		if isExpiredAuthToken(err) {
+			if aerr := c.reauthorize(context.Background()); aerr != nil {
+				return err
+			}
+			continue
+		}
+		if backoffRetry && isRetryableStatus(err) {
+			time.Sleep(backoff)
+			if backoff < 64*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		return err
+	}
+	return err
 }
 
 // Bucket is a reference to a B2 bucket.
 type Bucket struct {
-	b *base.Bucket
+	b    *base.Bucket
+	c    *Client
+	name string
+
+	// epoch is the Client's authEpoch as of when b was last (re)bound. A
+	// *base.Bucket is tied to the *base.B2 session it was obtained from,
+	// so once c has re-authorized (bumping its authEpoch), b is stale and
+	// must be rebound via rebind before use.
+	epoch int64
+
+	// uploadURLPool caches upload URL tokens obtained from GetUploadURL so
+	// that repeated small-file uploads don't each pay for a fresh one; B2
+	// upload URLs stay valid across uploads until they return an error.
+	uploadURLPool sync.Pool
 }
 
-// Bucket returns the named bucket, if it exists.
-func (c *Client) Bucket(ctx context.Context, name string) (*Bucket, error) {
-	buckets, err := c.b2.ListBuckets(ctx)
+// rebind re-derives b's *base.Bucket handle from the Client's current
+// session if the Client has re-authorized since b was last (re)bound,
+// since the old handle is tied to the now-replaced session.
+func (b *Bucket) rebind(ctx context.Context) error {
+	epoch := b.c.epoch()
+	if b.epoch == epoch {
+		return nil
+	}
+	nb, err := b.c.findBucket(ctx, b.name)
+	if err != nil {
+		return err
+	}
+	b.b = nb
+	b.epoch = epoch
+	return nil
+}
+
+// pooledUploadURL wraps an upload or part-upload URL token with the
+// authEpoch it was obtained in, so a re-authorization (which ties new
+// upload URLs to a new API host) doesn't leave stale ones being handed
+// back out of the pool.
+type pooledUploadURL struct {
+	url   interface{} // *base.URL or *base.PartURL
+	epoch int64
+}
+
+// getUploadURL returns a cached upload URL if one is available, and
+// otherwise requests a fresh one.
+func (b *Bucket) getUploadURL(ctx context.Context) (*base.URL, error) {
+	if v := b.uploadURLPool.Get(); v != nil {
+		pu := v.(pooledUploadURL)
+		if pu.epoch == b.c.epoch() {
+			return pu.url.(*base.URL), nil
+		}
+	}
+	if err := b.rebind(ctx); err != nil {
+		return nil, err
+	}
+	var ue *base.URL
+	err := b.c.call("b2_get_upload_url", func() error {
+		var e error
+		ue, e = b.b.GetUploadURL(ctx)
+		return e
+	})
+	return ue, err
+}
+
+// putUploadURL returns an upload URL to the pool for reuse, unless it's
+// known to be bad, in which case it's discarded so the next caller gets a
+// fresh one.
+func (b *Bucket) putUploadURL(ue *base.URL, bad bool) {
+	if ue == nil || bad {
+		return
+	}
+	b.uploadURLPool.Put(pooledUploadURL{url: ue, epoch: b.c.epoch()})
+}
+
+// CapabilityError reports that the application key a Client was
+// constructed with can't perform some operation, either because it lacks a
+// capability or because it's restricted to a different bucket than the one
+// requested.
+type CapabilityError struct {
+	Capability string
+	BucketID   string
+}
+
+func (e *CapabilityError) Error() string {
+	if e.BucketID != "" {
+		return fmt.Sprintf("b2: application key is restricted to bucket %s", e.BucketID)
+	}
+	return fmt.Sprintf("b2: application key lacks capability %q", e.Capability)
+}
+
+// isUnauthorized reports whether err is the B2 "unauthorized" response,
+// which for an application key usually means it lacks a capability rather
+// than that the credentials themselves are bad.
+func isUnauthorized(err error) bool {
+	se, ok := err.(interface{ StatusCode() int })
+	return ok && se.StatusCode() == http.StatusUnauthorized
+}
+
+// findBucket looks up the named bucket's current *base.Bucket handle,
+// which is tied to c's current session; it's used both by Bucket and by
+// Bucket.rebind, which re-derives a Bucket's handle after a reauthorization.
+func (c *Client) findBucket(ctx context.Context, name string) (*base.Bucket, error) {
+	var buckets []*base.Bucket
+	err := c.call("b2_list_buckets", func() error {
+		var e error
+		buckets, e = c.getB2().ListBuckets(ctx)
+		return e
+	})
+	if isUnauthorized(err) {
+		return nil, &CapabilityError{Capability: "listBuckets"}
+	}
 	if err != nil {
 		return nil, err
 	}
 	for _, bucket := range buckets {
-		if bucket.Name == name {
-			return &Bucket{
-				b: bucket,
-			}, nil
+		if bucket.Name != name {
+			continue
+		}
+		if id := c.getB2().AllowedBucketID; id != "" && bucket.ID != id {
+			return nil, &CapabilityError{BucketID: id}
 		}
+		return bucket, nil
 	}
 	// TODO: create bucket
 	return nil, fmt.Errorf("%s: no such bucket", name)
 }
 
+// Bucket returns the named bucket, if it exists and the Client's
+// application key (if any) is allowed to access it.
+func (c *Client) Bucket(ctx context.Context, name string) (*Bucket, error) {
+	b, err := c.findBucket(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{
+		b:     b,
+		c:     c,
+		name:  name,
+		epoch: c.epoch(),
+	}, nil
+}
+
+// WriterOption configures the behavior of a Writer returned by NewWriter.
+type WriterOption func(*Writer)
+
+// Resume, when true, causes the Writer to look for an existing unfinished
+// large file upload with the same name, content type, and info before
+// starting a new one, and to skip re-uploading any chunk whose part has
+// already been stored with the same SHA1.  It has no effect on files small enough to use the
+// simple (non-large-file) upload API.
+func Resume(resume bool) WriterOption {
+	return func(bw *Writer) {
+		bw.resume = resume
+	}
+}
+
 // NewWriter returns a new writer for the given file.
-func (b *Bucket) NewWriter(ctx context.Context, name, contentType string, info map[string]string) *Writer {
+func (b *Bucket) NewWriter(ctx context.Context, name, contentType string, info map[string]string, opts ...WriterOption) *Writer {
 	bw := &Writer{
-		bucket: b.b,
-		name:   name,
-		ctype:  contentType,
-		info:   info,
-		chsh:   sha1.New(),
-		cbuf:   &bytes.Buffer{},
-		ctx:    ctx,
+		o:     object{bkt: b},
+		name:  name,
+		ctype: contentType,
+		info:  info,
+		chsh:  sha1.New(),
+		cbuf:  &bytes.Buffer{},
+		ctx:   ctx,
+	}
+	for _, opt := range opts {
+		opt(bw)
 	}
 	bw.w = io.MultiWriter(bw.chsh, bw.cbuf)
+	b.c.addWriter(bw)
 	return bw
 }
 
+// UnfinishedLargeFile describes a large file upload that was started but
+// never finished or canceled.
+type UnfinishedLargeFile struct {
+	ID          string
+	Name        string
+	ContentType string
+	Info        map[string]string
+}
+
+// ListUnfinishedLargeFiles lists large file uploads in the bucket that have
+// been started but not yet finished or canceled, which is useful for
+// finding an upload to resume with the Resume WriterOption.  It returns a
+// page of files and, if there are more, a cursor to pass as the next call's
+// cursor argument.
+func (b *Bucket) ListUnfinishedLargeFiles(ctx context.Context, cursor string) ([]*UnfinishedLargeFile, string, error) {
+	if err := b.rebind(ctx); err != nil {
+		return nil, "", err
+	}
+	var files []*base.LargeFile
+	var next string
+	err := b.c.call("b2_list_unfinished_large_files", func() error {
+		var e error
+		files, next, e = b.b.ListUnfinishedLargeFiles(ctx, cursor)
+		return e
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	var out []*UnfinishedLargeFile
+	for _, f := range files {
+		out = append(out, &UnfinishedLargeFile{
+			ID:          f.ID,
+			Name:        f.Name,
+			ContentType: f.ContentType,
+			Info:        f.Info,
+		})
+	}
+	return out, next, nil
+}
+
+// CancelLargeFile cancels an unfinished large file upload, freeing any
+// storage it has already consumed.
+func (b *Bucket) CancelLargeFile(ctx context.Context, fileID string) error {
+	if err := b.rebind(ctx); err != nil {
+		return err
+	}
+	return b.c.call("b2_cancel_large_file", func() error {
+		return b.b.CancelLargeFile(ctx, fileID)
+	})
+}
+
+// NewReader returns a new reader for the given file.  The Reader must be
+// closed when the caller is done with it.
+func (b *Bucket) NewReader(ctx context.Context, name string) *Reader {
+	r := &Reader{
+		ctx:  ctx,
+		o:    object{bkt: b},
+		name: name,
+	}
+	b.c.addReader(r)
+	return r
+}
+
+// object identifies a single file within a bucket.
+type object struct {
+	bkt *Bucket
+}
+
+// client returns the owning Client, or nil if this object wasn't created
+// through a Bucket obtained from a Client.
+func (o object) client() *Client {
+	if o.bkt == nil {
+		return nil
+	}
+	return o.bkt.c
+}
+
+// call instruments a single outgoing B2 API call made on behalf of this
+// object; see (*Client).call.
+func (o object) call(method string, f func() error) error {
+	return o.client().call(method, f)
+}
+
+// callOnce instruments a single outgoing B2 API call made on behalf of this
+// object; see (*Client).callOnce.
+func (o object) callOnce(method string, f func() error) error {
+	return o.client().callOnce(method, f)
+}
+
+// base returns the bucket's current *base.Bucket handle, rebinding it
+// first if the Client has re-authorized since it was last (re)bound (see
+// Bucket.rebind) so callers never use one tied to an expired session.
+func (o object) base(ctx context.Context) (*base.Bucket, error) {
+	if err := o.bkt.rebind(ctx); err != nil {
+		return nil, err
+	}
+	return o.bkt.b, nil
+}
+
 type chunk struct {
 	id      int
 	attempt int
@@ -95,11 +522,33 @@ type Writer struct {
 	once  sync.Once
 	done  sync.Once
 	file  *base.LargeFile
+	// fileEpoch is the Client authEpoch as of when file was last (re)bound;
+	// see (*Writer).largeFile.
+	fileEpoch int64
+
+	o     object
+	name  string
+	ctype string
+	info  map[string]string
 
-	bucket *base.Bucket
-	name   string
-	ctype  string
-	info   map[string]string
+	resume   bool
+	existing map[int]string // part number -> SHA1, for parts already uploaded
+
+	// partURLPool caches part-upload URL tokens obtained from
+	// GetUploadPartURL, shared across this Writer's threads, so a thread
+	// only pays for a fresh one when none is spare.
+	partURLPool sync.Pool
+
+	// sentBytes is the total number of bytes successfully uploaded so far,
+	// for use by ShowStats.  It's updated atomically since it's written by
+	// multiple upload threads.
+	sentBytes int64
+
+	// werr is the first terminal error reported by a thread, once a chunk
+	// has exhausted TotalRetries.  Close returns it instead of finishing
+	// the large file.
+	werrMu sync.Mutex
+	werr   error
 
 	cbuf *bytes.Buffer
 	cidx int
@@ -107,13 +556,78 @@ type Writer struct {
 	w    io.Writer
 }
 
+// largeFile returns bw.file, rebinding it first if the Client has
+// re-authorized since it was last (re)bound: a *base.LargeFile is tied to
+// the *base.Bucket (and therefore session) it was obtained from, so the
+// stale one would keep hitting the old, now-invalid session forever.
+func (bw *Writer) largeFile(ctx context.Context) (*base.LargeFile, error) {
+	epoch := bw.o.client().epoch()
+	if bw.fileEpoch == epoch {
+		return bw.file, nil
+	}
+	bb, err := bw.o.base(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lf, err := bb.ResumeLargeFile(ctx, bw.file.ID)
+	if err != nil {
+		return nil, err
+	}
+	bw.file = lf
+	bw.fileEpoch = epoch
+	return lf, nil
+}
+
+// getPartURL returns a cached part-upload URL if one is available, and
+// otherwise requests a fresh one from the large file.
+func (bw *Writer) getPartURL() (*base.PartURL, error) {
+	if v := bw.partURLPool.Get(); v != nil {
+		pu := v.(pooledUploadURL)
+		if pu.epoch == bw.o.client().epoch() {
+			return pu.url.(*base.PartURL), nil
+		}
+	}
+	lf, err := bw.largeFile(bw.ctx)
+	if err != nil {
+		return nil, err
+	}
+	var fc *base.PartURL
+	err = bw.o.call("b2_get_upload_part_url", func() error {
+		var e error
+		fc, e = lf.GetUploadPartURL(bw.ctx)
+		return e
+	})
+	return fc, err
+}
+
+// putPartURL returns a part-upload URL to the pool for reuse by another
+// thread, unless it's known to be bad, in which case it's discarded.
+func (bw *Writer) putPartURL(fc *base.PartURL, bad bool) {
+	if fc == nil || bad {
+		return
+	}
+	bw.partURLPool.Put(pooledUploadURL{url: fc, epoch: bw.o.client().epoch()})
+}
+
+// setErr records err as the Writer's terminal error, if one hasn't already
+// been recorded.
+func (bw *Writer) setErr(err error) {
+	bw.werrMu.Lock()
+	defer bw.werrMu.Unlock()
+	if bw.werr == nil {
+		bw.werr = err
+	}
+}
+
+// getErr returns the Writer's terminal error, if any.
+func (bw *Writer) getErr() error {
+	bw.werrMu.Lock()
+	defer bw.werrMu.Unlock()
+	return bw.werr
+}
+
 func (bw *Writer) thread() {
 	go func() {
-		fc, err := bw.file.GetUploadPartURL(bw.ctx)
-		if err != nil {
-			log.Print(err)
-			return
-		}
 		bw.wg.Add(1)
 		defer bw.wg.Done()
 		for {
@@ -121,12 +635,31 @@ func (bw *Writer) thread() {
 			if !ok {
 				return
 			}
-			if _, err := fc.UploadPart(bw.ctx, chunk.buf, chunk.sha1, chunk.size, chunk.id); err != nil {
+			fc, err := bw.getPartURL()
+			if err == nil {
+				err = bw.o.callOnce("b2_upload_part", func() error {
+					_, e := fc.UploadPart(bw.ctx, chunk.buf, chunk.sha1, chunk.size, chunk.id)
+					return e
+				})
+			}
+			if err != nil {
 				log.Print(err)
+				bw.putPartURL(fc, true)
+				if chunk.attempt >= bw.TotalRetries {
+					// Retry budget exhausted: record the error and drop the
+					// chunk instead of resending it, so the other threads
+					// can still drain and exit once bw.ready is closed
+					// rather than deadlocking (or racing a send against
+					// Close's close(bw.ready)) forever.
+					bw.setErr(err)
+					continue
+				}
 				chunk.attempt++
 				bw.ready <- chunk
 				continue
 			}
+			atomic.AddInt64(&bw.sentBytes, int64(chunk.size))
+			bw.putPartURL(fc, false)
 		}
 	}()
 }
@@ -149,26 +682,107 @@ func (bw *Writer) Write(p []byte) (int, error) {
 }
 
 func (bw *Writer) simpleWriteFile() error {
-	ue, err := bw.bucket.GetUploadURL(bw.ctx)
+	ue, err := bw.o.bkt.getUploadURL(bw.ctx)
 	if err != nil {
 		return err
 	}
 	sha1 := fmt.Sprintf("%x", bw.chsh.Sum(nil))
-	if _, err := ue.UploadFile(bw.ctx, bw.cbuf, bw.cbuf.Len(), bw.name, bw.ctype, sha1, bw.info); err != nil {
+	size := bw.cbuf.Len()
+	err = bw.o.call("b2_upload_file", func() error {
+		_, e := ue.UploadFile(bw.ctx, bw.cbuf, size, bw.name, bw.ctype, sha1, bw.info)
+		return e
+	})
+	if err != nil {
+		bw.o.bkt.putUploadURL(ue, true)
 		return err
 	}
+	atomic.AddInt64(&bw.sentBytes, int64(size))
+	bw.o.bkt.putUploadURL(ue, false)
 	return nil
 }
 
+// findResumableFile looks for an unfinished large file upload matching this
+// Writer's name, content type, and info.  If one is found, it returns a
+// handle to it along with the SHA1 of each part already stored, keyed by
+// part number.
+func (bw *Writer) findResumableFile() (*base.LargeFile, map[int]string, error) {
+	bb, err := bw.o.base(bw.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cursor string
+	for {
+		var files []*base.LargeFile
+		var next string
+		err := bw.o.call("b2_list_unfinished_large_files", func() error {
+			var e error
+			files, next, e = bb.ListUnfinishedLargeFiles(bw.ctx, cursor)
+			return e
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range files {
+			if f.Name != bw.name || f.ContentType != bw.ctype || !reflect.DeepEqual(f.Info, bw.info) {
+				continue
+			}
+			lf, err := bb.ResumeLargeFile(bw.ctx, f.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			var parts []base.Part
+			err = bw.o.call("b2_list_parts", func() error {
+				var e error
+				parts, e = lf.ListParts(bw.ctx)
+				return e
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			existing := make(map[int]string, len(parts))
+			for _, p := range parts {
+				existing[p.Number] = p.SHA1
+			}
+			return lf, existing, nil
+		}
+		if next == "" {
+			return nil, nil, nil
+		}
+		cursor = next
+	}
+}
+
 func (bw *Writer) sendChunk() error {
 	var err error
 	bw.once.Do(func() {
-		lf, e := bw.bucket.StartLargeFile(bw.ctx, bw.name, bw.ctype, bw.info)
-		if e != nil {
-			err = e
-			return
+		if bw.resume {
+			if lf, existing, e := bw.findResumableFile(); e != nil {
+				log.Printf("b2 writer %s: find resumable upload: %v", bw.name, e)
+			} else if lf != nil {
+				bw.file = lf
+				bw.existing = existing
+				bw.fileEpoch = bw.o.client().epoch()
+			}
+		}
+		if bw.file == nil {
+			bb, e := bw.o.base(bw.ctx)
+			if e != nil {
+				err = e
+				return
+			}
+			var lf *base.LargeFile
+			e = bw.o.call("b2_start_large_file", func() error {
+				var e error
+				lf, e = bb.StartLargeFile(bw.ctx, bw.name, bw.ctype, bw.info)
+				return e
+			})
+			if e != nil {
+				err = e
+				return
+			}
+			bw.file = lf
+			bw.fileEpoch = bw.o.client().epoch()
 		}
-		bw.file = lf
 		bw.ready = make(chan chunk)
 		if bw.ConcurrentUploads < 1 {
 			bw.ConcurrentUploads = 1
@@ -180,12 +794,15 @@ func (bw *Writer) sendChunk() error {
 	if err != nil {
 		return err
 	}
-	bw.ready <- chunk{
+	c := chunk{
 		id:   bw.cidx + 1,
 		size: bw.cbuf.Len(),
 		sha1: fmt.Sprintf("%x", bw.chsh.Sum(nil)),
 		buf:  bw.cbuf,
 	}
+	if existingSHA1, ok := bw.existing[c.id]; !ok || existingSHA1 != c.sha1 {
+		bw.ready <- c
+	}
 	bw.cidx++
 	bw.chsh = sha1.New()
 	bw.cbuf = &bytes.Buffer{}
@@ -193,10 +810,30 @@ func (bw *Writer) sendChunk() error {
 	return nil
 }
 
+// cancel best-effort cancels the in-progress large file upload so that it
+// stops consuming storage, rather than leaving it to be cleaned up by hand.
+func (bw *Writer) cancel() {
+	if bw.file == nil {
+		return
+	}
+	bb, err := bw.o.base(bw.ctx)
+	if err != nil {
+		log.Printf("b2 writer %s: cancel large file: %v", bw.name, err)
+		return
+	}
+	err = bw.o.call("b2_cancel_large_file", func() error {
+		return bb.CancelLargeFile(bw.ctx, bw.file.ID)
+	})
+	if err != nil {
+		log.Printf("b2 writer %s: cancel large file: %v", bw.name, err)
+	}
+}
+
 // Close satisfies the io.Closer interface.
 func (bw *Writer) Close() error {
 	var oerr error
 	bw.done.Do(func() {
+		defer bw.o.client().removeWriter(bw)
 		if bw.cidx == 0 {
 			oerr = bw.simpleWriteFile()
 			return
@@ -204,15 +841,352 @@ func (bw *Writer) Close() error {
 		if bw.cbuf.Len() > 0 {
 			if err := bw.sendChunk(); err != nil {
 				oerr = err
+				bw.cancel()
 				return
 			}
 		}
 		close(bw.ready)
 		bw.wg.Wait()
-		if _, err := bw.file.FinishLargeFile(bw.ctx); err != nil {
+		if err := bw.getErr(); err != nil {
+			oerr = err
+			bw.cancel()
+			return
+		}
+		lf, err := bw.largeFile(bw.ctx)
+		if err != nil {
+			oerr = err
+			bw.cancel()
+			return
+		}
+		err = bw.o.call("b2_finish_large_file", func() error {
+			_, e := lf.FinishLargeFile(bw.ctx)
+			return e
+		})
+		if err != nil {
 			oerr = err
+			bw.cancel()
 			return
 		}
 	})
 	return oerr
 }
+
+// rchunk is a single downloaded range of a file, identified by its index
+// within the sequence of fixed-size ranges that make up the whole object.
+type rchunk struct {
+	id   int
+	data []byte
+	err  error
+}
+
+// Reader reads data from Backblaze.  It satisfies io.ReadCloser and
+// io.ReaderAt.  The zero value, aside from the fields set by NewReader, is
+// ready to use.
+type Reader struct {
+	// ConcurrentDownloads is the number of chunks fetched in parallel ahead
+	// of a streaming Read.  This can increase throughput greatly on
+	// high-latency or high-bandwidth links.  Values less than 1 are
+	// equivalent to 1.  It has no effect on ReadAt beyond bounding how many
+	// chunks of a single call are fetched at once.
+	ConcurrentDownloads int
+
+	// TotalRetries is the number of times a failed chunk download will be
+	// retried, each time against a freshly obtained download URL, before
+	// Read or ReadAt returns an error.  Values less than 1 mean the default
+	// of 5.
+	TotalRetries int
+
+	// ChunkSize is the size, in bytes, of each range requested from
+	// Backblaze.  The default is 10MB.
+	ChunkSize int
+
+	ctx  context.Context
+	o    object
+	name string
+
+	mu      sync.Mutex
+	size    int64
+	sizeSet bool
+	closed  bool
+
+	// recvBytes is the total number of bytes successfully downloaded so
+	// far, for use by ShowStats.  It's updated atomically since it's
+	// written by multiple prefetch workers.
+	recvBytes int64
+
+	once    sync.Once
+	reqCh   chan int
+	rspCh   chan rchunk
+	pending map[int]rchunk
+	next    int
+	// requested is the lowest chunk id not yet sent to reqCh, so
+	// requestAhead can ramp the prefetch window up gradually as chunk 0's
+	// response reveals the file's size, instead of start dispatching the
+	// full window of ids up front before the size (and therefore which of
+	// them are actually in range) is known.
+	requested int
+	cur       []byte
+	err       error
+}
+
+func (r *Reader) chunkSize() int {
+	if r.ChunkSize < 1 {
+		return 1e7
+	}
+	return r.ChunkSize
+}
+
+func (r *Reader) concurrentDownloads() int {
+	if r.ConcurrentDownloads < 1 {
+		return 1
+	}
+	return r.ConcurrentDownloads
+}
+
+func (r *Reader) totalRetries() int {
+	if r.TotalRetries < 1 {
+		return 5
+	}
+	return r.TotalRetries
+}
+
+// fetchChunk downloads the range of the file identified by id, verifying the
+// SHA1 that Backblaze returns for it.  On failure it obtains a fresh
+// download URL and retries, up to TotalRetries times.
+func (r *Reader) fetchChunk(id int) rchunk {
+	size := int64(r.chunkSize())
+	offset := int64(id) * size
+
+	var lastErr error
+	for attempt := 0; attempt <= r.totalRetries(); attempt++ {
+		bb, err := r.o.base(r.ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var du *base.DownloadURL
+		err = r.o.callOnce("b2_get_download_url", func() error {
+			var e error
+			du, e = bb.GetDownloadURL(r.ctx)
+			return e
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var fr *base.FileReader
+		err = r.o.callOnce("b2_download_file_by_name", func() error {
+			var e error
+			fr, e = du.DownloadFileByName(r.ctx, r.name, offset, size)
+			return e
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		buf := &bytes.Buffer{}
+		h := sha1.New()
+		_, cerr := io.Copy(io.MultiWriter(buf, h), fr)
+		total := fr.TotalSize()
+		if err := fr.Close(); err != nil && cerr == nil {
+			cerr = err
+		}
+		if cerr != nil {
+			lastErr = cerr
+			continue
+		}
+		if got := fmt.Sprintf("%x", h.Sum(nil)); got != fr.SHA1() {
+			lastErr = fmt.Errorf("b2 reader %s: chunk %d: sha1 mismatch: got %s, want %s", r.name, id, got, fr.SHA1())
+			continue
+		}
+		r.mu.Lock()
+		if !r.sizeSet {
+			r.size, r.sizeSet = total, true
+		}
+		r.mu.Unlock()
+		atomic.AddInt64(&r.recvBytes, int64(buf.Len()))
+		return rchunk{id: id, data: buf.Bytes()}
+	}
+	return rchunk{id: id, err: lastErr}
+}
+
+// start launches the prefetch workers on the first Read.
+func (r *Reader) start() {
+	r.once.Do(func() {
+		n := r.concurrentDownloads()
+		r.reqCh = make(chan int, n)
+		r.rspCh = make(chan rchunk, n)
+		r.pending = make(map[int]rchunk)
+		for i := 0; i < n; i++ {
+			go r.worker()
+		}
+		// Request only the first chunk up front.  Its response reveals
+		// the file's size, which requestAhead needs to know how much of
+		// the rest of the ConcurrentDownloads prefetch window is
+		// actually in range; dispatching the full window before that is
+		// known would issue genuinely out-of-range range requests for
+		// any file smaller than ConcurrentDownloads*ChunkSize.
+		r.requested = 1
+		r.reqCh <- 0
+	})
+}
+
+func (r *Reader) worker() {
+	for id := range r.reqCh {
+		r.rspCh <- r.fetchChunk(id)
+	}
+}
+
+// requestAhead keeps up to ConcurrentDownloads chunks in flight by
+// requesting every not-yet-requested id up to the end of the current
+// window, unless it's already known to be past the end of the file.
+func (r *Reader) requestAhead() {
+	limit := r.next + r.concurrentDownloads() - 1
+	for r.requested <= limit {
+		r.mu.Lock()
+		pastEOF := r.sizeSet && int64(r.requested)*int64(r.chunkSize()) >= r.size
+		r.mu.Unlock()
+		if pastEOF {
+			return
+		}
+		select {
+		case r.reqCh <- r.requested:
+			r.requested++
+		default:
+			return
+		}
+	}
+}
+
+// fill blocks until the next chunk in sequence arrives and makes it the
+// current read buffer.  Because requestAhead refuses to enqueue a chunk id
+// it already knows to be past the end of the file, fill can't rely on ever
+// seeing a response for r.next once that happens; it must recognize EOF
+// itself, both before waiting on a chunk that will never arrive and by
+// noticing a short (smaller than chunkSize) chunk once one does arrive.
+func (r *Reader) fill() error {
+	for {
+		r.mu.Lock()
+		pastEOF := r.sizeSet && int64(r.next)*int64(r.chunkSize()) >= r.size
+		r.mu.Unlock()
+		if pastEOF {
+			return io.EOF
+		}
+		if c, ok := r.pending[r.next]; ok {
+			delete(r.pending, r.next)
+			r.next++
+			r.requestAhead()
+			if c.err != nil {
+				return c.err
+			}
+			r.cur = c.data
+			if len(c.data) < r.chunkSize() {
+				return io.EOF
+			}
+			return nil
+		}
+		c := <-r.rspCh
+		r.pending[c.id] = c
+	}
+}
+
+// Read satisfies the io.Reader interface.  It streams the file in order,
+// prefetching up to ConcurrentDownloads chunks ahead.
+func (r *Reader) Read(p []byte) (int, error) {
+	r.start()
+	if r.closed {
+		return 0, fmt.Errorf("b2 reader %s: read on closed reader", r.name)
+	}
+	if len(r.cur) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if err := r.fill(); err != nil {
+			r.err = err
+			if len(r.cur) == 0 {
+				return 0, err
+			}
+		}
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// ReadAt satisfies the io.ReaderAt interface.  It fetches exactly the
+// chunks needed to cover [off, off+len(p)), using up to ConcurrentDownloads
+// workers to fetch them in parallel, and does not disturb the sequential
+// state used by Read.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	size := int64(r.chunkSize())
+	first := int(off / size)
+	last := int((off + int64(len(p)) - 1) / size)
+
+	workers := r.concurrentDownloads()
+	if span := last - first + 1; workers > span {
+		workers = span
+	}
+
+	ids := make(chan int)
+	res := make(chan rchunk)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for id := range ids {
+				res <- r.fetchChunk(id)
+			}
+		}()
+	}
+	go func() {
+		for id := first; id <= last; id++ {
+			ids <- id
+		}
+		close(ids)
+	}()
+
+	chunks := make(map[int]rchunk, last-first+1)
+	for i := first; i <= last; i++ {
+		c := <-res
+		chunks[c.id] = c
+	}
+
+	var n int
+	for id := first; id <= last; id++ {
+		c := chunks[id]
+		if c.err != nil {
+			return n, c.err
+		}
+		start := int64(0)
+		if id == first {
+			start = off - int64(id)*size
+		}
+		if start >= int64(len(c.data)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], c.data[start:])
+		if int64(len(c.data)) < size {
+			if n < len(p) {
+				return n, io.EOF
+			}
+			break
+		}
+	}
+	return n, nil
+}
+
+// Close satisfies the io.Closer interface.
+func (r *Reader) Close() error {
+	defer r.o.client().removeReader(r)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.reqCh != nil {
+		close(r.reqCh)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// +build integration
+
+package b2
+
+import (
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// These tests exercise a Writer against a live B2 account with failure
+// injection enabled, via FailSomeUploads.  They're gated behind the
+// "integration" build tag and B2_ACCOUNT_ID/B2_APPLICATION_KEY/B2_BUCKET
+// env vars because they make real billed API calls.
+func getEnvClient(ctx context.Context, t *testing.T, opts ...ClientOption) (*Client, *Bucket) {
+	id := os.Getenv("B2_ACCOUNT_ID")
+	key := os.Getenv("B2_APPLICATION_KEY")
+	bucket := os.Getenv("B2_BUCKET")
+	if id == "" || key == "" || bucket == "" {
+		t.Skip("B2_ACCOUNT_ID, B2_APPLICATION_KEY, and B2_BUCKET must be set for integration tests")
+	}
+	client, err := NewClient(ctx, id, key, opts...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	bkt, err := client.Bucket(ctx, bucket)
+	if err != nil {
+		t.Fatalf("Bucket: %v", err)
+	}
+	return client, bkt
+}
+
+// TestWriterRetriesRecoverFromFailedUploads verifies that the chunk-retry
+// loop in thread() recovers from the transient failures that
+// FailSomeUploads injects, and that the finished object is intact.
+func TestWriterRetriesRecoverFromFailedUploads(t *testing.T) {
+	ctx := context.Background()
+	_, bkt := getEnvClient(ctx, t, FailSomeUploads())
+
+	w := bkt.NewWriter(ctx, "blazer-chunk0-6-retry-test", "application/octet-stream", nil)
+	w.ConcurrentUploads = 4
+	w.TotalRetries = 10
+
+	// Past the 1e8-byte large-file threshold in Writer.Write, and spanning
+	// several parts across the 4 concurrent threads, so the chunks
+	// actually flow through thread() instead of taking the simpleWriteFile
+	// path.
+	data := make([]byte, 250*1024*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v (expected the retry loop to recover)", err)
+	}
+}
+
+// TestWriterCloseReturnsErrorWhenRetriesExhausted verifies that Close
+// returns the last chunk error, instead of hanging, once TotalRetries is
+// exhausted for a chunk.
+func TestWriterCloseReturnsErrorWhenRetriesExhausted(t *testing.T) {
+	ctx := context.Background()
+	_, bkt := getEnvClient(ctx, t, FailSomeUploads())
+
+	w := bkt.NewWriter(ctx, "blazer-chunk0-6-exhaust-test", "application/octet-stream", nil)
+	w.ConcurrentUploads = 4
+	w.TotalRetries = 0 // no retries: the first injected failure should be terminal
+
+	data := make([]byte, 250*1024*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close: got nil error, want the exhausted chunk's error")
+	}
+}
@@ -0,0 +1,114 @@
+package b2
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/kurin/gozer/base"
+)
+
+// Key is a B2 application key.
+type Key struct {
+	c *Client
+
+	ID   string
+	Name string
+
+	// Secret is the key's secret.  B2 only returns it on the Key returned
+	// by CreateKey; it's empty on keys returned by ListKeys.
+	Secret string
+
+	Capabilities []string
+	BucketID     string    // empty if the key isn't restricted to a bucket
+	NamePrefix   string    // empty if the key isn't restricted to a name prefix
+	Expires      time.Time // zero if the key doesn't expire
+}
+
+// KeyOption configures a key created with CreateKey.
+type KeyOption func(*keyOptions)
+
+type keyOptions struct {
+	validDurationSeconds int
+	bucketID             string
+	namePrefix           string
+}
+
+// ValidDurationSeconds causes the created key to automatically expire this
+// many seconds after creation.
+func ValidDurationSeconds(secs int) KeyOption {
+	return func(o *keyOptions) { o.validDurationSeconds = secs }
+}
+
+// KeyBucketID restricts the created key to the given bucket.
+func KeyBucketID(bucketID string) KeyOption {
+	return func(o *keyOptions) { o.bucketID = bucketID }
+}
+
+// KeyNamePrefix restricts the created key to file names beginning with
+// prefix.
+func KeyNamePrefix(prefix string) KeyOption {
+	return func(o *keyOptions) { o.namePrefix = prefix }
+}
+
+func newKey(c *Client, bk *base.Key) *Key {
+	return &Key{
+		c:            c,
+		ID:           bk.ID,
+		Name:         bk.Name,
+		Secret:       bk.Secret,
+		Capabilities: bk.Capabilities,
+		BucketID:     bk.BucketID,
+		NamePrefix:   bk.NamePrefix,
+		Expires:      bk.Expires,
+	}
+}
+
+// CreateKey creates a new application key with the given name and
+// capabilities (e.g. "listBuckets", "readFiles", "writeFiles"; see the B2
+// docs for the full list).  The Secret field of the returned Key is the
+// only time its secret is available; B2 never returns it again.
+func (c *Client) CreateKey(ctx context.Context, name string, caps []string, opts ...KeyOption) (*Key, error) {
+	var o keyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var bk *base.Key
+	err := c.call("b2_create_key", func() error {
+		var e error
+		bk, e = c.getB2().CreateKey(ctx, name, caps, o.validDurationSeconds, o.bucketID, o.namePrefix)
+		return e
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newKey(c, bk), nil
+}
+
+// ListKeys lists up to max of the account's application keys, starting
+// at startKey (pass "" to list from the beginning).  If there are more
+// keys, it returns a non-empty cursor to pass as the next call's startKey.
+func (c *Client) ListKeys(ctx context.Context, max int, startKey string) ([]*Key, string, error) {
+	var bks []*base.Key
+	var next string
+	err := c.call("b2_list_keys", func() error {
+		var e error
+		bks, next, e = c.getB2().ListKeys(ctx, max, startKey)
+		return e
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	keys := make([]*Key, len(bks))
+	for i, bk := range bks {
+		keys[i] = newKey(c, bk)
+	}
+	return keys, next, nil
+}
+
+// Delete deletes the key.
+func (k *Key) Delete(ctx context.Context) error {
+	return k.c.call("b2_delete_key", func() error {
+		return k.c.getB2().DeleteKey(ctx, k.ID)
+	})
+}
@@ -15,12 +15,23 @@
 package b2
 
 import (
+	"encoding/json"
 	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"math/bits"
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ShowStats causes b2 to listen for http on the given network address, where
-// it displays information about what it's doing.
+// it displays information about what it's doing: per-method call counts,
+// error rates, and latency distributions, plus a list of the writers and
+// readers currently in flight.
 func (c *Client) ShowStats(addr string) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", c.infoHandler)
@@ -28,10 +39,34 @@ func (c *Client) ShowStats(addr string) {
 }
 
 func (c *Client) infoHandler(rw http.ResponseWriter, req *http.Request) {
-	rw.Write([]byte("hello, world"))
+	view := struct {
+		Methods []methodSnapshot
+		Writers []writerSnapshot
+		Readers []readerSnapshot
+	}{
+		Methods: c.stats.snapshot(),
+		Writers: c.writerSnapshots(),
+		Readers: c.readerSnapshots(),
+	}
+
+	if req.URL.Query().Get("format") == "json" {
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(view); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statsTemplate.Execute(rw, view); err != nil {
+		log.Print(err)
+	}
 }
 
 func (c *Client) addWriter(w *Writer) {
+	if c == nil {
+		return
+	}
 	c.slock.Lock()
 	defer c.slock.Unlock()
 
@@ -39,10 +74,13 @@ func (c *Client) addWriter(w *Writer) {
 		c.sWriters = make(map[string]*Writer)
 	}
 
-	c.sWriters[fmt.Sprintf("%s/%s", w.o.b.Name, w.name)] = w
+	c.sWriters[fmt.Sprintf("%s/%s", w.o.bkt.name, w.name)] = w
 }
 
 func (c *Client) removeWriter(w *Writer) {
+	if c == nil {
+		return
+	}
 	c.slock.Lock()
 	defer c.slock.Unlock()
 
@@ -50,5 +88,371 @@ func (c *Client) removeWriter(w *Writer) {
 		return
 	}
 
-	delete(c.sWriters, fmt.Sprintf("%s/%s", w.o.b.Name, w.name))
-}
\ No newline at end of file
+	delete(c.sWriters, fmt.Sprintf("%s/%s", w.o.bkt.name, w.name))
+}
+
+func (c *Client) addReader(r *Reader) {
+	if c == nil {
+		return
+	}
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	if c.sReaders == nil {
+		c.sReaders = make(map[string]*Reader)
+	}
+
+	c.sReaders[fmt.Sprintf("%s/%s", r.o.bkt.name, r.name)] = r
+}
+
+func (c *Client) removeReader(r *Reader) {
+	if c == nil {
+		return
+	}
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	if c.sReaders == nil {
+		return
+	}
+
+	delete(c.sReaders, fmt.Sprintf("%s/%s", r.o.bkt.name, r.name))
+}
+
+// writerSnapshot is a point-in-time view of a live Writer, for ShowStats.
+type writerSnapshot struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes_sent"`
+	Chunk int    `json:"chunk"`
+}
+
+// readerSnapshot is a point-in-time view of a live Reader, for ShowStats.
+type readerSnapshot struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes_received"`
+	Chunk int    `json:"chunk"`
+}
+
+// writerSnapshots returns a point-in-time, best-effort view of every live
+// Writer; the byte counts and chunk indices are read without locking out
+// the writer, so they may be slightly stale.
+func (c *Client) writerSnapshots() []writerSnapshot {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	out := make([]writerSnapshot, 0, len(c.sWriters))
+	for key, w := range c.sWriters {
+		out = append(out, writerSnapshot{
+			Name:  key,
+			Bytes: atomic.LoadInt64(&w.sentBytes),
+			Chunk: w.cidx,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// readerSnapshots returns a point-in-time, best-effort view of every live
+// Reader; see writerSnapshots.
+func (c *Client) readerSnapshots() []readerSnapshot {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	out := make([]readerSnapshot, 0, len(c.sReaders))
+	for key, r := range c.sReaders {
+		out = append(out, readerSnapshot{
+			Name:  key,
+			Bytes: atomic.LoadInt64(&r.recvBytes),
+			Chunk: r.next,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// --- per-method call metrics ---
+
+const (
+	// secBuckets is the number of one-second buckets kept, giving a
+	// rolling one-minute window.
+	secBuckets = 60
+	// fiveSecBuckets is the number of five-second buckets kept, giving a
+	// rolling five-minute window.
+	fiveSecBuckets = 60
+	fiveSecSpan    = 5 * time.Second
+
+	// histBuckets is the number of power-of-two latency buckets kept per
+	// statBucket, giving an HDR-style exponential histogram good enough
+	// for approximate p50/p90/p99 without the bookkeeping of a full
+	// t-digest.  32 buckets covers latencies from 1ms to about 50 days
+	// (2^32ms); anything longer is counted in the last bucket.
+	histBuckets = 32
+)
+
+// latHist is a power-of-two latency histogram.
+type latHist struct {
+	counts [histBuckets]int64
+}
+
+func (h *latHist) add(d time.Duration) {
+	ms := d.Nanoseconds() / 1e6
+	if ms < 1 {
+		ms = 1
+	}
+	b := bits.Len64(uint64(ms)) - 1
+	if b >= histBuckets {
+		b = histBuckets - 1
+	}
+	h.counts[b]++
+}
+
+func (h latHist) merge(o latHist) latHist {
+	var out latHist
+	for i := range out.counts {
+		out.counts[i] = h.counts[i] + o.counts[i]
+	}
+	return out
+}
+
+// quantile returns the approximate duration below which a fraction q of
+// observations fall, rounded down to a power of two milliseconds (the lower
+// bound of the histogram bucket the quantile lands in).
+func (h latHist) quantile(q float64) time.Duration {
+	var total int64
+	for _, c := range h.counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(q * float64(total)))
+	var cum int64
+	for b, c := range h.counts {
+		cum += c
+		if cum >= target {
+			return time.Duration(int64(1)<<uint(b)) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// statBucket aggregates calls to one B2 API method over some span of time:
+// a single second, a five-second span, or (for the all-time total) forever.
+type statBucket struct {
+	key   int64 // the bucket's position in its ring, e.g. a unix second
+	count int64
+	errs  map[string]int64 // error count keyed by HTTP status class: "4xx", "5xx", "other"
+	sum   float64          // total latency, in seconds
+	hist  latHist
+}
+
+func (b *statBucket) add(d time.Duration, errClass string) {
+	b.count++
+	b.sum += d.Seconds()
+	b.hist.add(d)
+	if errClass != "" {
+		if b.errs == nil {
+			b.errs = map[string]int64{}
+		}
+		b.errs[errClass]++
+	}
+}
+
+// rollup is the result of summing a range of statBuckets, ready for
+// display.
+type rollup struct {
+	Count int64            `json:"count"`
+	Errs  map[string]int64 `json:"errors,omitempty"`
+	Mean  time.Duration    `json:"mean_ns"`
+	P50   time.Duration    `json:"p50_ns"`
+	P90   time.Duration    `json:"p90_ns"`
+	P99   time.Duration    `json:"p99_ns"`
+}
+
+func rollupOf(b statBucket) rollup {
+	r := rollup{Count: b.count, Errs: b.errs}
+	if b.count > 0 {
+		r.Mean = time.Duration(b.sum / float64(b.count) * float64(time.Second))
+	}
+	r.P50 = b.hist.quantile(0.5)
+	r.P90 = b.hist.quantile(0.9)
+	r.P99 = b.hist.quantile(0.99)
+	return r
+}
+
+// methodStats holds the in-flight count and call history for a single B2
+// API method, at three granularities: a rolling minute (one-second
+// buckets), a rolling five minutes (five-second buckets), and an all-time
+// total.
+type methodStats struct {
+	mu       sync.Mutex
+	inFlight int64
+
+	minute [secBuckets]statBucket
+	five   [fiveSecBuckets]statBucket
+	total  statBucket
+}
+
+func errClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if se, ok := err.(interface{ StatusCode() int }); ok {
+		switch code := se.StatusCode(); {
+		case code >= 500:
+			return "5xx"
+		case code >= 400:
+			return "4xx"
+		}
+	}
+	return "other"
+}
+
+func bucketFor(ring []statBucket, key int64) *statBucket {
+	b := &ring[((key%int64(len(ring)))+int64(len(ring)))%int64(len(ring))]
+	if b.key != key {
+		*b = statBucket{key: key}
+	}
+	return b
+}
+
+func (m *methodStats) record(d time.Duration, err error) {
+	now := time.Now()
+	cls := errClass(err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bucketFor(m.minute[:], now.Unix()).add(d, cls)
+	bucketFor(m.five[:], now.Unix()/int64(fiveSecSpan/time.Second)).add(d, cls)
+	m.total.add(d, cls)
+}
+
+// sumRing sums the n buckets of ring ending at nowKey, skipping any that
+// have aged out (i.e. whose stored key doesn't match the expected one).
+func sumRing(ring []statBucket, nowKey int64, n int) statBucket {
+	var out statBucket
+	for i := 0; i < n; i++ {
+		key := nowKey - int64(i)
+		b := &ring[((key%int64(len(ring)))+int64(len(ring)))%int64(len(ring))]
+		if b.key != key {
+			continue
+		}
+		out.count += b.count
+		out.sum += b.sum
+		out.hist = out.hist.merge(b.hist)
+		for k, v := range b.errs {
+			if out.errs == nil {
+				out.errs = map[string]int64{}
+			}
+			out.errs[k] += v
+		}
+	}
+	return out
+}
+
+// methodSnapshot is the serializable view of one method's rolled-up stats.
+type methodSnapshot struct {
+	Method   string `json:"method"`
+	InFlight int64  `json:"in_flight"`
+	Minute   rollup `json:"last_minute"`
+	Five     rollup `json:"last_five_minutes"`
+	Total    rollup `json:"total"`
+}
+
+func (m *methodStats) snapshot(method string) methodSnapshot {
+	m.mu.Lock()
+	minute := sumRing(m.minute[:], time.Now().Unix(), secBuckets)
+	five := sumRing(m.five[:], time.Now().Unix()/int64(fiveSecSpan/time.Second), fiveSecBuckets)
+	total := m.total
+	m.mu.Unlock()
+
+	return methodSnapshot{
+		Method:   method,
+		InFlight: atomic.LoadInt64(&m.inFlight),
+		Minute:   rollupOf(minute),
+		Five:     rollupOf(five),
+		Total:    rollupOf(total),
+	}
+}
+
+// metrics holds a methodStats per instrumented B2 API method.
+type metrics struct {
+	mu      sync.Mutex
+	methods map[string]*methodStats
+}
+
+func (ms *metrics) forMethod(method string) *methodStats {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.methods == nil {
+		ms.methods = make(map[string]*methodStats)
+	}
+	m, ok := ms.methods[method]
+	if !ok {
+		m = &methodStats{}
+		ms.methods[method] = m
+	}
+	return m
+}
+
+func (ms *metrics) snapshot() []methodSnapshot {
+	ms.mu.Lock()
+	names := make([]string, 0, len(ms.methods))
+	methods := make(map[string]*methodStats, len(ms.methods))
+	for name, m := range ms.methods {
+		names = append(names, name)
+		methods[name] = m
+	}
+	ms.mu.Unlock()
+	sort.Strings(names)
+
+	out := make([]methodSnapshot, 0, len(names))
+	for _, name := range names {
+		out = append(out, methods[name].snapshot(name))
+	}
+	return out
+}
+
+var statsTemplate = template.Must(template.New("stats").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond)) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>b2 client stats</title></head>
+<body>
+<h1>b2 client stats</h1>
+
+<h2>API calls</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr>
+  <th rowspan="2">method</th><th rowspan="2">in-flight</th>
+  <th colspan="4">last minute</th><th colspan="4">last 5 minutes</th><th colspan="4">total</th>
+</tr>
+<tr>
+  <th>count</th><th>errors</th><th>p50 (ms)</th><th>p99 (ms)</th>
+  <th>count</th><th>errors</th><th>p50 (ms)</th><th>p99 (ms)</th>
+  <th>count</th><th>errors</th><th>p50 (ms)</th><th>p99 (ms)</th>
+</tr>
+{{range .Methods}}
+<tr>
+  <td>{{.Method}}</td><td>{{.InFlight}}</td>
+  <td>{{.Minute.Count}}</td><td>{{.Minute.Errs}}</td><td>{{ms .Minute.P50}}</td><td>{{ms .Minute.P99}}</td>
+  <td>{{.Five.Count}}</td><td>{{.Five.Errs}}</td><td>{{ms .Five.P50}}</td><td>{{ms .Five.P99}}</td>
+  <td>{{.Total.Count}}</td><td>{{.Total.Errs}}</td><td>{{ms .Total.P50}}</td><td>{{ms .Total.P99}}</td>
+</tr>
+{{end}}
+</table>
+
+<h2>active writers</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>name</th><th>bytes sent</th><th>chunk</th></tr>
+{{range .Writers}}<tr><td>{{.Name}}</td><td>{{.Bytes}}</td><td>{{.Chunk}}</td></tr>{{end}}
+</table>
+
+<h2>active readers</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>name</th><th>bytes received</th><th>chunk</th></tr>
+{{range .Readers}}<tr><td>{{.Name}}</td><td>{{.Bytes}}</td><td>{{.Chunk}}</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))